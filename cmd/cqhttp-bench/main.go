@@ -0,0 +1,59 @@
+// Command cqhttp-bench 是一个内置的压测工具, 用于测量 go-cqhttp OneBot 事件/接口的吞吐与延迟
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Mrs4s/go-cqhttp/modules/bench"
+)
+
+func main() {
+	mode := flag.String("mode", "forward", "压测模式: forward(连接到正向WS服务器) 或 reverse(作为反向WS服务器等待连接)")
+	target := flag.String("target", "ws://127.0.0.1:6700/api", "forward模式下的WS地址, reverse模式下的监听地址")
+	token := flag.String("token", "", "AccessToken")
+	workers := flag.Int("workers", 10, "并发worker数")
+	requests := flag.Int("requests", 100, "每个worker发出的请求数")
+	actionsFlag := flag.String("actions", "get_group_member_list:1", "逗号分隔的 action:weight 列表, 例如 send_group_msg:5,get_group_member_list:1")
+	flag.Parse()
+
+	actions, err := parseActions(*actionsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	report, err := bench.Run(bench.Config{
+		Mode:     *mode,
+		Target:   *target,
+		Token:    *token,
+		Workers:  *workers,
+		Requests: *requests,
+		Actions:  actions,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(report.String())
+}
+
+func parseActions(s string) ([]bench.Action, error) {
+	var actions []bench.Action
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, ":", 2)
+		weight := 1
+		if len(kv) == 2 {
+			w, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("无效的权重: %v", part)
+			}
+			weight = w
+		}
+		actions = append(actions, bench.Action{Name: kv[0], Params: map[string]interface{}{}, Weight: weight})
+	}
+	return actions, nil
+}
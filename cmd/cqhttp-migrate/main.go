@@ -0,0 +1,39 @@
+// Command cqhttp-migrate 将默认 LevelDB 后端中已持久化的历史消息迁移到 MongoDB 后端,
+// 是 db.MigrateLevelDBToMongo 目前唯一的调用入口
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Mrs4s/go-cqhttp/db"
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+func main() {
+	from := flag.String("from", "data/leveldb", "待迁移的 LevelDB 数据目录")
+	uri := flag.String("uri", "mongodb://127.0.0.1:27017", "目标 MongoDB 连接串")
+	database := flag.String("database", "cqhttp", "目标 MongoDB 数据库名")
+	poolSize := flag.Uint64("pool-size", 0, "MongoDB 连接池最大连接数, 0表示使用驱动默认值")
+	flag.Parse()
+
+	dst, err := db.New(config.Database{
+		Backend: "mongodb",
+		MongoDB: config.MongoDBConfig{
+			Enable:   true,
+			URI:      *uri,
+			Database: *database,
+			PoolSize: *poolSize,
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if err := db.MigrateLevelDBToMongo(*from, dst); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+// Command cqhttp-replay 从数据库中回放 [start, end] 时间范围内的历史消息并打印到标准输出,
+// 是 bench.ReplayEvents 目前唯一的调用入口
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Mrs4s/go-cqhttp/db"
+	"github.com/Mrs4s/go-cqhttp/modules/bench"
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+func main() {
+	uri := flag.String("uri", "mongodb://127.0.0.1:27017", "MongoDB 连接串")
+	database := flag.String("database", "cqhttp", "MongoDB 数据库名")
+	start := flag.Int64("start", 0, "回放时间范围起点(unix时间戳)")
+	end := flag.Int64("end", 0, "回放时间范围终点(unix时间戳)")
+	flag.Parse()
+
+	source, err := db.New(config.Database{
+		Backend: "mongodb",
+		MongoDB: config.MongoDBConfig{Enable: true, URI: *uri, Database: *database},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	rangeDB, ok := source.(db.IRangeQueryDatabase)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "所选后端不支持按时间范围检索历史消息")
+		os.Exit(1)
+	}
+
+	err = bench.ReplayEvents(rangeDB, *start, *end, func(msg db.IStoredMessage) {
+		fmt.Printf("[%s] globalId=%d\n", msg.GetType(), msg.GetGlobalID())
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
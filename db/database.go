@@ -25,6 +25,18 @@ type (
 		InsertPrivateMessage(*StoredPrivateMessage) error
 	}
 
+	// IRangeQueryDatabase 是支持按时间范围/发送者检索历史消息的 IDatabase 实现所额外满足的接口,
+	// 如 MongoDB. 这类查询在 LevelDB 这样的纯KV后端上没有高效实现, 因此没有被并入 IDatabase,
+	// 调用方(如 bench.ReplayEvents)需显式依赖本接口, 而不是对所有 IDatabase 实现都强制要求
+	IRangeQueryDatabase interface {
+		IDatabase
+
+		// GetMessagesByTimeRange 获取 [start, end] 时间范围(unix时间戳)内的群聊与私聊消息
+		GetMessagesByTimeRange(start, end int64) ([]IStoredMessage, error)
+		// GetMessagesBySender 获取指定发送者的群聊与私聊消息
+		GetMessagesBySender(sender int64) ([]IStoredMessage, error)
+	}
+
 	IStoredMessage interface {
 		GetID() string
 		GetType() string
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+// New 根据 conf.Backend 构建并 Open 对应的 IDatabase 实现. 目前仅 mongodb 后端在本仓库中
+// 有实现; leveldb(含默认空值)会返回错误, 而不是静默地不持久化任何消息.
+func New(conf config.Database) (IDatabase, error) {
+	switch conf.Backend {
+	case "mongodb":
+		if !conf.MongoDB.Enable {
+			return nil, fmt.Errorf("database.backend 为 mongodb, 但 database.mongodb.enable 未开启")
+		}
+		m := &MongoDB{
+			URI:      conf.MongoDB.URI,
+			Database: conf.MongoDB.Database,
+			PoolSize: conf.MongoDB.PoolSize,
+		}
+		if err := m.Open(); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "leveldb", "":
+		return nil, fmt.Errorf("database.backend 为 leveldb, 但本构建未包含 LevelDB 后端实现")
+	default:
+		return nil, fmt.Errorf("未知的 database.backend: %v, 应为 leveldb 或 mongodb", conf.Backend)
+	}
+}
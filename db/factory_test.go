@@ -0,0 +1,32 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+// TestNewUnknownBackend 确保 Backend 取值之外的拼写错误会被直接拒绝, 而不是静默回退到某个后端
+func TestNewUnknownBackend(t *testing.T) {
+	if _, err := New(config.Database{Backend: "redis"}); err == nil {
+		t.Fatal("未知的 Backend 应当返回错误")
+	}
+}
+
+// TestNewLevelDBNotImplemented 确保选择 leveldb(含默认空值)会得到明确的错误, 而不是让调用方
+// 以为消息已经被持久化
+func TestNewLevelDBNotImplemented(t *testing.T) {
+	for _, backend := range []string{"leveldb", ""} {
+		if _, err := New(config.Database{Backend: backend}); err == nil {
+			t.Fatalf("backend=%q 在本构建下应返回错误", backend)
+		}
+	}
+}
+
+// TestNewMongoDBRequiresEnable 确保 Backend=mongodb 时仍然校验 mongodb.enable,
+// 避免只改了 backend 却忘记打开 mongodb.enable 的配置被静默忽略
+func TestNewMongoDBRequiresEnable(t *testing.T) {
+	if _, err := New(config.Database{Backend: "mongodb"}); err == nil {
+		t.Fatal("mongodb.enable 未开启时应返回错误")
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// MigrateLevelDBToMongo 将 levelDBPath 处 LevelDB 中已持久化的群聊/私聊消息逐条迁移到 dst,
+// 用于从默认的 LevelDB 后端切换到 MongoDB. 单条记录迁移失败只会被跳过并记录日志, 不会中断整体迁移.
+func MigrateLevelDBToMongo(levelDBPath string, dst IDatabase) error {
+	source, err := leveldb.OpenFile(levelDBPath, nil)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	iter := source.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var migrated, skipped int
+	for iter.Next() {
+		key := string(iter.Key())
+		value := iter.Value()
+		switch {
+		case len(key) > 2 && key[:2] == "g_":
+			msg := &StoredGroupMessage{}
+			if err := json.Unmarshal(value, msg); err != nil {
+				log.Warnf("解析群消息记录 %v 失败, 已跳过: %v", key, err)
+				skipped++
+				continue
+			}
+			if err := dst.InsertGroupMessage(msg); err != nil {
+				log.Warnf("迁移群消息 %v 失败, 已跳过: %v", msg.ID, err)
+				skipped++
+				continue
+			}
+			migrated++
+		case len(key) > 2 && key[:2] == "p_":
+			msg := &StoredPrivateMessage{}
+			if err := json.Unmarshal(value, msg); err != nil {
+				log.Warnf("解析私聊消息记录 %v 失败, 已跳过: %v", key, err)
+				skipped++
+				continue
+			}
+			if err := dst.InsertPrivateMessage(msg); err != nil {
+				log.Warnf("迁移私聊消息 %v 失败, 已跳过: %v", msg.ID, err)
+				skipped++
+				continue
+			}
+			migrated++
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	log.Infof("LevelDB 到 MongoDB 迁移完成: 成功 %d 条, 跳过 %d 条", migrated, skipped)
+	return nil
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mongoTimeout = time.Second * 10
+
+// MongoDB 是基于 go.mongodb.org/mongo-driver 的 IDatabase 实现, 可替代默认的 LevelDB 后端
+type MongoDB struct {
+	URI      string
+	Database string
+	// PoolSize 连接池最大连接数, 0表示使用驱动默认值
+	PoolSize uint64
+
+	// mu 保护 client/group/priv: reconnect 会在其他goroutine经由 queryGroupMessages 等方法
+	// 并发读取这三个字段的同时替换它们, 没有锁保护会出现数据竞争甚至对已关闭连接的空解引用
+	mu     sync.RWMutex
+	client *mongo.Client
+	group  *mongo.Collection
+	priv   *mongo.Collection
+}
+
+// Open 连接 MongoDB 并确保查询所需的索引存在
+func (m *MongoDB) Open() error {
+	opts := options.Client().ApplyURI(m.URI)
+	if m.PoolSize > 0 {
+		opts.SetMaxPoolSize(m.PoolSize)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err = client.Ping(ctx, nil); err != nil {
+		return err
+	}
+	database := client.Database(m.Database)
+	group := database.Collection("group_messages")
+	priv := database.Collection("private_messages")
+
+	m.mu.Lock()
+	m.client = client
+	m.group = group
+	m.priv = priv
+	m.mu.Unlock()
+
+	return m.ensureIndexes(ctx)
+}
+
+func (m *MongoDB) ensureIndexes(ctx context.Context) error {
+	group, priv := m.collections()
+	if _, err := group.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "globalId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "groupCode", Value: 1}}},
+		{Keys: bson.D{{Key: "attribute.timestamp", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+	_, err := priv.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "globalId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sessionUin", Value: 1}, {Key: "targetUin", Value: 1}}},
+		{Keys: bson.D{{Key: "attribute.timestamp", Value: 1}}},
+	})
+	return err
+}
+
+// collections 以读锁获取当前生效的 group/priv 集合句柄, 保证读到的是同一次连接下的一致快照,
+// 不会出现 reconnect 替换到一半时一个是旧连接一个是新连接的情况
+func (m *MongoDB) collections() (*mongo.Collection, *mongo.Collection) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.group, m.priv
+}
+
+// withRetry 执行一次数据库操作, 若失败则重新建立连接后重试一次, 使瞬时的网络抖动
+// 不会直接导致 InsertGroupMessage/InsertPrivateMessage 等调用方收到错误
+func (m *MongoDB) withRetry(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	err := fn(ctx)
+	cancel()
+	if err == nil {
+		return nil
+	}
+	log.Warnf("MongoDB 操作失败, 尝试重新连接后重试: %v", err)
+	if rerr := m.reconnect(); rerr != nil {
+		log.Warnf("重新连接 MongoDB 失败: %v", rerr)
+		return err
+	}
+	ctx2, cancel2 := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel2()
+	return fn(ctx2)
+}
+
+func (m *MongoDB) reconnect() error {
+	m.mu.RLock()
+	client := m.client
+	m.mu.RUnlock()
+	if client != nil {
+		_ = client.Disconnect(context.Background())
+	}
+	return m.Open()
+}
+
+// InsertGroupMessage 向数据库写入新的群消息
+func (m *MongoDB) InsertGroupMessage(msg *StoredGroupMessage) error {
+	return m.withRetry(func(ctx context.Context) error {
+		group, _ := m.collections()
+		_, err := group.InsertOne(ctx, msg)
+		return err
+	})
+}
+
+// InsertPrivateMessage 向数据库写入新的私聊消息
+func (m *MongoDB) InsertPrivateMessage(msg *StoredPrivateMessage) error {
+	return m.withRetry(func(ctx context.Context) error {
+		_, priv := m.collections()
+		_, err := priv.InsertOne(ctx, msg)
+		return err
+	})
+}
+
+// GetGroupMessageByGlobalID 通过 GlobalID 来获取群消息
+func (m *MongoDB) GetGroupMessageByGlobalID(id int32) (*StoredGroupMessage, error) {
+	msg := &StoredGroupMessage{}
+	err := m.withRetry(func(ctx context.Context) error {
+		group, _ := m.collections()
+		return group.FindOne(ctx, bson.M{"globalId": id}).Decode(msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetPrivateMessageByGlobalID 通过 GlobalID 来获取私聊消息
+func (m *MongoDB) GetPrivateMessageByGlobalID(id int32) (*StoredPrivateMessage, error) {
+	msg := &StoredPrivateMessage{}
+	err := m.withRetry(func(ctx context.Context) error {
+		_, priv := m.collections()
+		return priv.FindOne(ctx, bson.M{"globalId": id}).Decode(msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetMessageByGlobalID 通过 GlobalID 来获取消息, 依次尝试群消息与私聊消息
+func (m *MongoDB) GetMessageByGlobalID(id int32) (IStoredMessage, error) {
+	if msg, err := m.GetGroupMessageByGlobalID(id); err == nil {
+		return msg, nil
+	}
+	return m.GetPrivateMessageByGlobalID(id)
+}
+
+func (m *MongoDB) queryGroupMessages(ctx context.Context, filter bson.M) ([]IStoredMessage, error) {
+	group, _ := m.collections()
+	cur, err := group.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []IStoredMessage
+	for cur.Next(ctx) {
+		msg := &StoredGroupMessage{}
+		if err = cur.Decode(msg); err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+	return out, cur.Err()
+}
+
+func (m *MongoDB) queryPrivateMessages(ctx context.Context, filter bson.M) ([]IStoredMessage, error) {
+	_, priv := m.collections()
+	cur, err := priv.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []IStoredMessage
+	for cur.Next(ctx) {
+		msg := &StoredPrivateMessage{}
+		if err = cur.Decode(msg); err != nil {
+			return nil, err
+		}
+		out = append(out, msg)
+	}
+	return out, cur.Err()
+}
+
+// GetMessagesByTimeRange 获取 [start, end] 时间范围(unix时间戳)内的群聊与私聊消息
+func (m *MongoDB) GetMessagesByTimeRange(start, end int64) ([]IStoredMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	filter := bson.M{"attribute.timestamp": bson.M{"$gte": start, "$lte": end}}
+	group, err := m.queryGroupMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := m.queryPrivateMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return append(group, priv...), nil
+}
+
+// GetMessagesBySender 获取指定发送者的群聊与私聊消息
+func (m *MongoDB) GetMessagesBySender(sender int64) ([]IStoredMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	filter := bson.M{"attribute.senderUin": sender}
+	group, err := m.queryGroupMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := m.queryPrivateMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return append(group, priv...), nil
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"github.com/tidwall/gjson"
+
+	"github.com/Mrs4s/go-cqhttp/global"
+	"github.com/Mrs4s/go-cqhttp/modules/auth"
+)
+
+// Context 携带一次 action 调用在 Router 中流转所需的全部信息, 与具体的传输层(WS/HTTP等)无关
+type Context struct {
+	// Action 要调用的API名称, 已去除 "_async" 后缀
+	Action string
+	// Params 调用参数
+	Params gjson.Result
+	// Echo 透传给客户端的回显字段, 不存在时 Exists() 为 false
+	Echo gjson.Result
+	// Transport 标识该调用来自的传输层, 如 "ws" 或 "http"
+	Transport string
+	// Claims 由鉴权中间件/传输层注入的JWT声明, 未使用JWT鉴权时为 nil. Dispatch 会在调用前
+	// 用它对 Action 做一次 HasScope 校验, 中间件也可据此实现更细粒度的控制
+	Claims *auth.Claims
+
+	result  global.MSG
+	aborted bool
+}
+
+// Abort 让中间件直接以 result 结束本次调用, 后续中间件与最终的 caller.Call 都不会再执行
+func (c *Context) Abort(result global.MSG) {
+	c.result = result
+	c.aborted = true
+}
+
+// Middleware 是作用于 Router 的中间件, 在最终的API调用前后做限流、鉴权、日志、指标等横切处理.
+// 中间件通过调用 next(ctx) 将请求交给下一个中间件, 不调用 next(或调用 ctx.Abort)即可中断请求.
+type Middleware func(ctx *Context, next func(*Context))
+
+// Router 是可在 HTTP、WebSocket 等不同传输层间共享的 action 分发器. 新增的传输方式只需要
+// 构造好 Context 并调用 Dispatch, 而不必各自重新实现一遍鉴权、限流等逻辑.
+type Router struct {
+	caller *Caller
+	chain  []Middleware
+}
+
+// NewRouter 基于 caller 创建一个 Router, 实际的API调用仍委托给 caller.Call 完成
+func NewRouter(caller *Caller) *Router {
+	return &Router{caller: caller}
+}
+
+// Use 注册一个中间件, 中间件按注册顺序依次包裹最终的调用, 如限流、日志、指标等
+func (r *Router) Use(m Middleware) {
+	r.chain = append(r.chain, m)
+}
+
+// Dispatch 执行 ctx 所携带的 action 调用: 依次经过已注册的中间件, 再校验 ctx.Claims 所声明的
+// 作用域, 最终交由 caller.Call 执行, 并在结果中附加 echo(若存在)
+func (r *Router) Dispatch(ctx *Context) global.MSG {
+	handler := func(c *Context) {
+		if c.aborted {
+			return
+		}
+		if c.Claims != nil && !c.Claims.HasScope(c.Action) {
+			c.Abort(global.MSG{"status": "failed", "retcode": 103, "msg": "scope not permitted", "wording": "当前凭证无权调用该API"})
+			return
+		}
+		c.result = r.caller.Call(c.Action, c.Params)
+	}
+	for i := len(r.chain) - 1; i >= 0; i-- {
+		mw, next := r.chain[i], handler
+		handler = func(c *Context) {
+			if c.aborted {
+				return
+			}
+			mw(c, next)
+		}
+	}
+	handler(ctx)
+	if ctx.Echo.Exists() && ctx.result != nil {
+		ctx.result["echo"] = ctx.Echo.Value()
+	}
+	return ctx.result
+}
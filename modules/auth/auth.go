@@ -0,0 +1,67 @@
+// Package auth 实现了基于JWT的访问凭证签发与校验, 作为静态 AccessToken 的补充,
+// 使操作者可以为不同客户端签发限定作用域、可通过缩短 ttl 快速失效的凭证.
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Claims 是签发给客户端的JWT所携带的声明
+type Claims struct {
+	User   string   `json:"user"`
+	Scopes []string `json:"scopes"`
+	jwt.StandardClaims
+}
+
+// HasScope 判断当前凭证是否具有指定的作用域, 未声明任何作用域的凭证视为拥有全部权限
+func (c *Claims) HasScope(scope string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateToken 使用 secret 为 user 签发一个有效期为 ttl、携带 scopes 的JWT
+func GenerateToken(secret, issuer, user string, scopes []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		User:   user,
+		Scopes: scopes,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// ParseToken 校验 tokenString 的签名与有效期, 并核对签发者, 返回其携带的声明
+func ParseToken(secret, issuer, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if issuer != "" && claims.Issuer != issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	return claims, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHasScopeEmptyScopesGrantsAll 未声明任何作用域的凭证(如静态AccessToken换算出的历史行为)
+// 应当拥有全部权限, 不能因为 Scopes 为空就把所有调用都拒绝掉
+func TestHasScopeEmptyScopesGrantsAll(t *testing.T) {
+	c := &Claims{}
+	if !c.HasScope("send_group_msg") {
+		t.Fatal("未声明 Scopes 的凭证应当拥有全部权限")
+	}
+}
+
+// TestHasScopeRestrictsToDeclaredActions 声明了具体作用域的凭证只能调用声明范围内的action
+func TestHasScopeRestrictsToDeclaredActions(t *testing.T) {
+	c := &Claims{Scopes: []string{"send_group_msg"}}
+	if !c.HasScope("send_group_msg") {
+		t.Fatal("声明范围内的action应当被放行")
+	}
+	if c.HasScope("set_group_kick") {
+		t.Fatal("声明范围外的action应当被拒绝")
+	}
+}
+
+// TestHasScopeWildcard "*" 作用域应放行任意action, 用于签发管理员凭证
+func TestHasScopeWildcard(t *testing.T) {
+	c := &Claims{Scopes: []string{"*"}}
+	if !c.HasScope("set_group_kick") {
+		t.Fatal("声明了 * 作用域的凭证应当拥有全部权限")
+	}
+}
+
+// TestGenerateAndParseTokenRoundTrip 验证签发的JWT能够被正确解析回同一套声明, 且伪造的
+// 签发者会被拒绝
+func TestGenerateAndParseTokenRoundTrip(t *testing.T) {
+	token, err := GenerateToken("secret", "go-cqhttp", "alice", []string{"send_group_msg"}, time.Hour)
+	if err != nil {
+		t.Fatalf("签发JWT失败: %v", err)
+	}
+
+	claims, err := ParseToken("secret", "go-cqhttp", token)
+	if err != nil {
+		t.Fatalf("校验JWT失败: %v", err)
+	}
+	if claims.User != "alice" || !claims.HasScope("send_group_msg") || claims.HasScope("set_group_kick") {
+		t.Fatalf("解析出的声明与签发时不一致: %+v", claims)
+	}
+
+	if _, err := ParseToken("secret", "other-issuer", token); err == nil {
+		t.Fatal("签发者不匹配的JWT应当被拒绝")
+	}
+	if _, err := ParseToken("wrong-secret", "go-cqhttp", token); err == nil {
+		t.Fatal("密钥不匹配的JWT应当被拒绝")
+	}
+}
@@ -0,0 +1,361 @@
+// Package bench 实现了一个内置的 OneBot 压测工具: 它既可以作为正向WS客户端连接到
+// webSocketServer, 也可以作为反向WS服务器供 websocketClient 拨入, 按配置的权重驱动一组
+// API 调用, 并以 go-stress-testing 的风格汇总 RPS、延迟分位数与错误分布.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/Mrs4s/go-cqhttp/db"
+)
+
+// Action 描述一次压测请求所使用的 API 调用及其在随机抽取时的相对权重
+type Action struct {
+	Name   string
+	Params map[string]interface{}
+	Weight int
+}
+
+// Config 是一次压测运行所需的全部参数
+type Config struct {
+	// Mode 取值为 forward(作为正向WS客户端连接到 Target) 或 reverse(作为反向WS服务器监听 Target)
+	Mode     string
+	Target   string
+	Token    string
+	Workers  int
+	Requests int // 每个worker发出的请求数
+	Actions  []Action
+}
+
+// Report 是一次压测结束后的延迟与错误分布汇总
+type Report struct {
+	Total         int
+	Errors        int
+	ErrorsByMsg   map[string]int
+	RPS           float64
+	P50, P95, P99 time.Duration
+}
+
+// String 以 go-stress-testing 风格格式化报告
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "请求总数: %d  失败数: %d  RPS: %.2f\n", r.Total, r.Errors, r.RPS)
+	fmt.Fprintf(b, "延迟 P50: %v  P95: %v  P99: %v\n", r.P50, r.P95, r.P99)
+	if len(r.ErrorsByMsg) > 0 {
+		b.WriteString("错误分布:\n")
+		for msg, count := range r.ErrorsByMsg {
+			fmt.Fprintf(b, "  %s: %d\n", msg, count)
+		}
+	}
+	return b.String()
+}
+
+// pickAction 按各 Action.Weight 的比例随机抽取一个, totalWeight 须预先校验为正, 调用方
+// (Run)保证了这一点, 故这里不再重复返回错误
+func (c Config) pickAction(totalWeight int) Action {
+	r := rand.Intn(totalWeight)
+	for _, a := range c.Actions {
+		if r < a.Weight {
+			return a
+		}
+		r -= a.Weight
+	}
+	return c.Actions[len(c.Actions)-1]
+}
+
+// Run 按 conf 驱动一次压测
+func Run(conf Config) (*Report, error) {
+	if len(conf.Actions) == 0 {
+		return nil, fmt.Errorf("至少需要指定一个action")
+	}
+	totalWeight := 0
+	for _, a := range conf.Actions {
+		if a.Weight <= 0 {
+			return nil, fmt.Errorf("action %v 的权重必须为正数, 实际为 %d", a.Name, a.Weight)
+		}
+		totalWeight += a.Weight
+	}
+	switch conf.Mode {
+	case "forward":
+		return runForward(conf, totalWeight)
+	case "reverse":
+		return runReverse(conf, totalWeight)
+	default:
+		return nil, fmt.Errorf("未知的压测模式: %v, 应为 forward 或 reverse", conf.Mode)
+	}
+}
+
+// runForward 以 conf.Workers 个并发连接, 作为正向WS客户端连接到 conf.Target 发起调用
+func runForward(conf Config, totalWeight int) (*Report, error) {
+	header := http.Header{}
+	if conf.Token != "" {
+		header["Authorization"] = []string{"Token " + conf.Token}
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errCount  int
+		errByMsg  = map[string]int{}
+		wg        sync.WaitGroup
+	)
+	recordErr := func(msg string) {
+		mu.Lock()
+		errCount++
+		errByMsg[msg]++
+		mu.Unlock()
+	}
+
+	start := time.Now()
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, _, err := websocket.DefaultDialer.Dial(conf.Target, header) // nolint
+			if err != nil {
+				recordErr(err.Error())
+				return
+			}
+			defer conn.Close()
+			for j := 0; j < conf.Requests; j++ {
+				lat, errMsg := call(conn, conf.pickAction(totalWeight), j)
+				if errMsg != "" {
+					recordErr(errMsg)
+					continue
+				}
+				mu.Lock()
+				latencies = append(latencies, lat)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return buildReport(latencies, errCount, errByMsg, time.Since(start)), nil
+}
+
+// runReverse 作为反向WS服务器监听 conf.Target, 等待唯一一个 websocketClient 拨入后驱动调用.
+// 由于只有这一条物理连接, conf.Workers 个并发worker通过 benchConn 按 echo 复用同一连接:
+// 每个worker各自阻塞等待自己那次调用的回包, 互不干扰, 与 server/cluster.go 中
+// clusterWorkerConn.call/deliver 多路复用一条集群连接的做法一致。
+func runReverse(conf Config, totalWeight int) (*Report, error) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	connCh := make(chan *websocket.Conn, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		select {
+		case connCh <- c:
+		default:
+			_ = c.Close()
+		}
+	})
+	srv := &http.Server{Addr: conf.Target, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Warnf("压测反向WS服务器退出: %v", err)
+		}
+	}()
+	defer srv.Close()
+
+	log.Infof("等待反向WS客户端连接到 %v ...", conf.Target)
+	conn := newBenchConn(<-connCh)
+	defer conn.Close()
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errCount  int
+		errByMsg  = map[string]int{}
+		wg        sync.WaitGroup
+	)
+	recordErr := func(msg string) {
+		mu.Lock()
+		errCount++
+		errByMsg[msg]++
+		mu.Unlock()
+	}
+
+	start := time.Now()
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < conf.Requests; j++ {
+				lat, errMsg := conn.call(conf.pickAction(totalWeight), worker, j)
+				if errMsg != "" {
+					recordErr(errMsg)
+					continue
+				}
+				mu.Lock()
+				latencies = append(latencies, lat)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return buildReport(latencies, errCount, errByMsg, time.Since(start)), nil
+}
+
+// benchConn 在单条反向WS连接上按 echo 多路复用多个并发worker的请求/响应, 使 runReverse 在
+// 只有一条物理连接的前提下仍能体现 conf.Workers 个并发调用方
+type benchConn struct {
+	conn *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan gjson.Result
+}
+
+func newBenchConn(c *websocket.Conn) *benchConn {
+	bc := &benchConn{conn: c, pending: make(map[string]chan gjson.Result)}
+	go bc.listen()
+	return bc
+}
+
+func (bc *benchConn) listen() {
+	for {
+		_, data, err := bc.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		ret := gjson.ParseBytes(data)
+		bc.deliver(ret.Get("echo").String(), ret)
+	}
+}
+
+func (bc *benchConn) deliver(echo string, ret gjson.Result) {
+	bc.mu.Lock()
+	ch, ok := bc.pending[echo]
+	if ok {
+		delete(bc.pending, echo)
+	}
+	bc.mu.Unlock()
+	if ok {
+		ch <- ret
+	}
+}
+
+func (bc *benchConn) Close() error {
+	return bc.conn.Close()
+}
+
+// call 发起一次API调用并阻塞等待其带 echo 的回包, 可被多个worker goroutine并发调用
+func (bc *benchConn) call(action Action, worker, seq int) (time.Duration, string) {
+	echo := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(worker) + "-" + strconv.Itoa(seq)
+	payload, err := json.Marshal(map[string]interface{}{"action": action.Name, "params": action.Params, "echo": echo})
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	ch := make(chan gjson.Result, 1)
+	bc.mu.Lock()
+	bc.pending[echo] = ch
+	bc.mu.Unlock()
+
+	t0 := time.Now()
+	bc.writeMu.Lock()
+	err = bc.conn.WriteMessage(websocket.TextMessage, payload)
+	bc.writeMu.Unlock()
+	if err != nil {
+		bc.mu.Lock()
+		delete(bc.pending, echo)
+		bc.mu.Unlock()
+		return 0, err.Error()
+	}
+
+	select {
+	case ret := <-ch:
+		lat := time.Since(t0)
+		if ret.Get("status").Str == "failed" {
+			return lat, ret.Get("msg").String()
+		}
+		return lat, ""
+	case <-time.After(time.Second * 30):
+		bc.mu.Lock()
+		delete(bc.pending, echo)
+		bc.mu.Unlock()
+		return time.Since(t0), "等待反向WS客户端返回结果超时"
+	}
+}
+
+// call 发起一次API调用并等待其返回, 返回耗时, 若调用失败或API返回失败则返回对应的错误描述
+func call(conn *websocket.Conn, action Action, seq int) (time.Duration, string) {
+	echo := fmt.Sprintf("%d-%d", time.Now().UnixNano(), seq)
+	payload, err := json.Marshal(map[string]interface{}{"action": action.Name, "params": action.Params, "echo": echo})
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	t0 := time.Now()
+	if err = conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		return 0, err.Error()
+	}
+	_, resp, err := conn.ReadMessage()
+	lat := time.Since(t0)
+	if err != nil {
+		return lat, err.Error()
+	}
+	ret := gjson.ParseBytes(resp)
+	if ret.Get("status").Str == "failed" {
+		return lat, ret.Get("msg").String()
+	}
+	return lat, ""
+}
+
+func buildReport(latencies []time.Duration, errCount int, errByMsg map[string]int, elapsed time.Duration) *Report {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	pick := func(p float64) time.Duration {
+		if len(latencies) == 0 {
+			return 0
+		}
+		idx := int(float64(len(latencies)-1) * p)
+		return latencies[idx]
+	}
+	total := len(latencies) + errCount
+	var rps float64
+	if elapsed > 0 {
+		rps = float64(total) / elapsed.Seconds()
+	}
+	return &Report{
+		Total:       total,
+		Errors:      errCount,
+		ErrorsByMsg: errByMsg,
+		RPS:         rps,
+		P50:         pick(0.50),
+		P95:         pick(0.95),
+		P99:         pick(0.99),
+	}
+}
+
+// ReplayEvents 从 database 中取出 [start, end] 时间范围(unix时间戳)内的历史消息, 依次交给 handler
+// 处理, 可用于在不连接真实协议的情况下, 结合 pprof 对 coolq.CQBot 的消息处理链路进行压测.
+// 按时间范围检索不是所有 IDatabase 实现都能高效支持, 因此这里依赖更窄的 db.IRangeQueryDatabase.
+func ReplayEvents(database db.IRangeQueryDatabase, start, end int64, handler func(db.IStoredMessage)) error {
+	messages, err := database.GetMessagesByTimeRange(start, end)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		handler(msg)
+	}
+	log.Infof("回放完成, 共处理 %d 条历史消息", len(messages))
+	return nil
+}
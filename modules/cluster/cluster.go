@@ -0,0 +1,182 @@
+// Package cluster 实现了 go-cqhttp 的多节点(master/worker)集群子系统.
+//
+// worker 节点以单个 go-cqhttp 实例的形式运行, 通过 WebSocket 注册到 Master 并持续转发
+// 自身产生的事件, 同时接受 Master 下发、由自己执行后再回传结果的 API 调用, 使得一个
+// Master 可以聚合多个 bot 对外暴露统一的 OneBot 接口.
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/Mrs4s/go-cqhttp/coolq"
+	"github.com/Mrs4s/go-cqhttp/global"
+	"github.com/Mrs4s/go-cqhttp/modules/api"
+	"github.com/Mrs4s/go-cqhttp/modules/filter"
+)
+
+// Worker 是集群的工作节点, 负责向 Master 注册、转发事件并执行 Master 下发的 API 调用
+type Worker struct {
+	bot *coolq.CQBot
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+
+	master            string
+	node              string
+	token             string
+	filter            string
+	reconnectInterval time.Duration
+	apiCaller         *api.Caller
+}
+
+// NewWorker 创建一个连接到 master 的集群 worker
+func NewWorker(b *coolq.CQBot, master, node, token, flt string, reconnectInterval time.Duration) *Worker {
+	filter.Add(flt)
+	return &Worker{
+		bot:               b,
+		master:            master,
+		node:              node,
+		token:             token,
+		filter:            flt,
+		reconnectInterval: reconnectInterval,
+		apiCaller:         api.NewCaller(b),
+	}
+}
+
+// Run 连接到 Master 并开始转发事件, 断线后按 reconnectInterval 重连
+func (w *Worker) Run() {
+	w.bot.OnEventPush(w.onBotPushEvent)
+	w.connect()
+}
+
+func (w *Worker) connect() {
+	log.Infof("正在尝试连接到集群 Master: %v", w.master)
+	header := http.Header{
+		"X-Node-Name": []string{w.node},
+		"X-Self-ID":   []string{strconv.FormatInt(w.bot.Client.Uin, 10)},
+	}
+	if w.token != "" {
+		header["Authorization"] = []string{"Token " + w.token}
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(w.master, header) // nolint
+	if err != nil {
+		log.Warnf("连接到集群 Master %v 时出现错误: %v", w.master, err)
+		w.retry()
+		return
+	}
+
+	log.Infof("已连接到集群 Master: %v (node: %v)", w.master, w.node)
+	w.mu.Lock()
+	w.conn = conn
+	w.mu.Unlock()
+
+	go w.heartbeat(conn)
+	w.listen(conn)
+}
+
+func (w *Worker) retry() {
+	if w.reconnectInterval <= 0 {
+		return
+	}
+	time.Sleep(w.reconnectInterval)
+	w.connect()
+}
+
+// heartbeat 每15秒向 Master 上报一次心跳以保持连接活跃, 写入失败或连接已被替换时退出.
+// Master 收到心跳后不做进一步处理, 也不据此判定连接失效
+func (w *Worker) heartbeat(conn *websocket.Conn) {
+	for {
+		time.Sleep(time.Second * 15)
+		if w.currentConn() != conn {
+			return
+		}
+		payload := fmt.Sprintf(`{"_cluster_event":"heartbeat","node":%q,"self_id":%d,"time":%d}`,
+			w.node, w.bot.Client.Uin, time.Now().Unix())
+		if err := w.writeText([]byte(payload)); err != nil {
+			return
+		}
+	}
+}
+
+func (w *Worker) listen(conn *websocket.Conn) {
+	defer func() { _ = conn.Close() }()
+	for {
+		buffer := global.NewBuffer()
+		t, reader, err := conn.NextReader()
+		if err != nil {
+			log.Warnf("监听集群 Master 下发消息时出现错误: %v", err)
+			global.PutBuffer(buffer)
+			break
+		}
+		_, err = buffer.ReadFrom(reader)
+		if err != nil {
+			global.PutBuffer(buffer)
+			break
+		}
+		if t == websocket.TextMessage {
+			go func(buffer *bytes.Buffer) {
+				defer global.PutBuffer(buffer)
+				w.handleAPI(buffer.Bytes())
+			}(buffer)
+		} else {
+			global.PutBuffer(buffer)
+		}
+	}
+	w.retry()
+}
+
+// handleAPI 执行 Master 路由下发的 API 调用, 并将结果连同 self_id 回传给 Master
+func (w *Worker) handleAPI(payload []byte) {
+	j := gjson.ParseBytes(payload)
+	action := j.Get("action").Str
+	ret := w.apiCaller.Call(action, j.Get("params"))
+	if j.Get("echo").Exists() {
+		ret["echo"] = j.Get("echo").Value()
+	}
+	ret["self_id"] = w.bot.Client.Uin
+	b, err := json.Marshal(ret)
+	if err != nil {
+		log.Warnf("序列化集群 API 调用结果时出现错误: %v", err)
+		return
+	}
+	if err = w.writeText(b); err != nil {
+		log.Warnf("向集群 Master 回传 API 调用结果时出现错误: %v", err)
+	}
+}
+
+func (w *Worker) onBotPushEvent(e *coolq.Event) {
+	flt := filter.Find(w.filter)
+	if flt != nil && !flt.Eval(gjson.Parse(e.JSONString())) {
+		log.Debugf("上报Event %s 到集群 Master 时被过滤.", e.JSONBytes())
+		return
+	}
+	payload := fmt.Sprintf(`{"node":%q,"self_id":%d,"event":%s}`, w.node, w.bot.Client.Uin, e.JSONBytes())
+	if err := w.writeText([]byte(payload)); err != nil {
+		log.Warnf("向集群 Master 转发事件时出现错误: %v", err)
+	}
+}
+
+func (w *Worker) currentConn() *websocket.Conn {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn
+}
+
+func (w *Worker) writeText(b []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return fmt.Errorf("未连接到集群 Master")
+	}
+	return w.conn.WriteMessage(websocket.TextMessage, b)
+}
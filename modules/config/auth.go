@@ -0,0 +1,23 @@
+package config
+
+// Auth 基于JWT的鉴权配置, 在原有静态 AccessToken 之外提供可撤销、可限定作用域的访问凭证
+type Auth struct {
+	Disabled bool `yaml:"disabled"`
+
+	// JWTSecret 用于签发与校验JWT的密钥
+	JWTSecret string `yaml:"jwt_secret"`
+	// Issuer JWT的签发者标识, 写入iss声明并在校验时核对
+	Issuer string `yaml:"issuer"`
+	// TTL 签发的JWT的有效期, 单位秒
+	TTL int64 `yaml:"ttl"`
+
+	// Users 允许通过 /auth/login 换取JWT的用户列表
+	Users []AuthUser `yaml:"users"`
+}
+
+// AuthUser 是一个可登录换取JWT的用户及其可用的API作用域
+type AuthUser struct {
+	Name     string   `yaml:"name"`
+	Password string   `yaml:"password"`
+	Scopes   []string `yaml:"scopes"`
+}
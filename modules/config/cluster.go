@@ -0,0 +1,26 @@
+package config
+
+// Cluster 多节点集群(master/worker)配置, 用于将多个 go-cqhttp 实例聚合到一个 Master 节点之后
+type Cluster struct {
+	Disabled bool `yaml:"disabled"`
+
+	// Mode 当前节点的运行模式, 取值为 master 或 worker
+	Mode string `yaml:"mode"`
+
+	// Master Master 节点的 WebSocket 地址, worker 模式下使用
+	Master string `yaml:"master"`
+	// Node 当前节点的名称, worker 注册时随握手请求发送, Master 仅在日志中用它标识节点;
+	// Master 区分与路由各 worker 只依据 self_id
+	Node string `yaml:"node"`
+
+	// Host Master 监听地址, master 模式下使用
+	Host string `yaml:"host"`
+	// Port Master 监听端口, master 模式下使用
+	Port uint16 `yaml:"port"`
+
+	AccessToken string `yaml:"access_token"`
+	Filter      string `yaml:"filter"`
+
+	// ReconnectInterval worker 与 Master 断线后的重连间隔, 单位毫秒, worker 模式下使用
+	ReconnectInterval int `yaml:"reconnect_interval"`
+}
@@ -0,0 +1,24 @@
+package config
+
+// Database 持久化消息存储的后端选择与连接配置
+type Database struct {
+	// Backend 选用的数据库后端, 取值为 leveldb 或 mongodb, 默认为 leveldb
+	Backend string `yaml:"backend"`
+
+	LevelDB LevelDBConfig `yaml:"leveldb"`
+	MongoDB MongoDBConfig `yaml:"mongodb"`
+}
+
+// LevelDBConfig LevelDB 后端配置
+type LevelDBConfig struct {
+	Enable bool `yaml:"enable"`
+}
+
+// MongoDBConfig MongoDB 后端配置
+type MongoDBConfig struct {
+	Enable   bool   `yaml:"enable"`
+	URI      string `yaml:"uri"`
+	Database string `yaml:"database"`
+	// PoolSize 连接池最大连接数, 0表示使用驱动默认值
+	PoolSize uint64 `yaml:"pool_size"`
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Mrs4s/go-cqhttp/coolq"
+	"github.com/Mrs4s/go-cqhttp/modules/auth"
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+// authLoginRequest 是 /auth/login 的请求体
+type authLoginRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// activeAuthConfig 持有当前生效的JWT鉴权配置, 由 runAuth 在启动时写入一次, 供 checkAuth/
+// authLoginHandler 在处理 WS/HTTP 服务器收到的每个请求时读取. 使用 atomic.Value 是因为
+// runAuth 与 runWSServer 分别由各自的配置节触发, 两者的启动顺序未作约定, 请求到来时
+// 读取的一定是当前已加载的配置, 而不是构造 mux 时的快照.
+var activeAuthConfig atomic.Value // *config.Auth
+
+func getAuthConfig() *config.Auth {
+	v, _ := activeAuthConfig.Load().(*config.Auth)
+	return v
+}
+
+// runAuth 加载JWT鉴权配置, 使客户端可以凭用户名密码在 /auth/login (挂载于正向WS服务器之上)
+// 换取一个限定作用域、短期有效的JWT, 用于替代在多个客户端间共享同一个静态 AccessToken
+func runAuth(_ *coolq.CQBot, node yaml.Node) {
+	var conf config.Auth
+	switch err := node.Decode(&conf); {
+	case err != nil:
+		log.Warn("读取JWT鉴权配置失败 :", err)
+		fallthrough
+	case conf.Disabled:
+		return
+	}
+	activeAuthConfig.Store(&conf)
+	log.Info("JWT鉴权已启用, /auth/login 登录端点将随正向WebSocket服务器一同提供服务")
+}
+
+// authLoginHandler 处理 /auth/login, 为用户名密码匹配的用户签发JWT. 挂载在 webSocketServer
+// 的 mux 上而非独立监听, 使其签发的凭证与 checkAuth 实际校验的是同一套配置.
+func authLoginHandler(w http.ResponseWriter, r *http.Request) {
+	conf := getAuthConfig()
+	if conf == nil || conf.Disabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req authLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, u := range conf.Users {
+		if u.Name != req.User || u.Password != req.Password {
+			continue
+		}
+		ttl := time.Duration(conf.TTL) * time.Second
+		token, err := auth.GenerateToken(conf.JWTSecret, conf.Issuer, u.Name, u.Scopes, ttl)
+		if err != nil {
+			log.Warnf("签发JWT时出现错误: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   conf.TTL,
+		})
+		return
+	}
+	log.Warnf("用户 %v 登录失败: 用户名或密码错误", req.User)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// checkBearerAuth 尝试解析请求头中的 Authorization: Bearer <jwt>, 由 checkAuth 在静态
+// AccessToken 校验之前优先调用, 解析失败时 checkAuth 再回退到静态 token 校验, 以便两种鉴权方式可以同时生效
+func checkBearerAuth(r *http.Request) (*auth.Claims, bool) {
+	conf := getAuthConfig()
+	if conf == nil || conf.Disabled {
+		return nil, false
+	}
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || h[:len(prefix)] != prefix {
+		return nil, false
+	}
+	claims, err := auth.ParseToken(conf.JWTSecret, conf.Issuer, h[len(prefix):])
+	if err != nil {
+		log.Debugf("校验JWT失败: %v", err)
+		return nil, false
+	}
+	return claims, true
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeYAMLNode(t *testing.T, s string) yaml.Node {
+	t.Helper()
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &node); err != nil {
+		t.Fatalf("解析测试用YAML失败: %v", err)
+	}
+	// node 是 DocumentNode, runAuth 期望的是其下唯一的映射节点, 与 yaml.Node.Decode 在
+	// runWSServer 等处读取各子配置节时的用法一致
+	return *node.Content[0]
+}
+
+// TestCheckAuthBearerEnforcesScope 端到端验证 /auth/login 签发的JWT能被 checkAuth 正确解析,
+// 且解析出的 Claims 如实反映登录时声明的作用域, 这是 Router.Dispatch 按作用域放行调用的前提
+func TestCheckAuthBearerEnforcesScope(t *testing.T) {
+	node := decodeYAMLNode(t, `
+jwt_secret: test-secret
+issuer: go-cqhttp-test
+ttl: 3600
+users:
+  - name: alice
+    password: hunter2
+    scopes: ["send_group_msg"]
+`)
+	runAuth(nil, node)
+
+	loginSrv := httptest.NewServer(http.HandlerFunc(authLoginHandler))
+	defer loginSrv.Close()
+
+	body, _ := json.Marshal(map[string]string{"user": "alice", "password": "hunter2"})
+	resp, err := http.Post(loginSrv.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("登录请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("登录应当成功, 实际状态码: %d", resp.StatusCode)
+	}
+	var loginResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		t.Fatalf("解析登录响应失败: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api", nil)
+	req.Header.Set("Authorization", "Bearer "+loginResp.AccessToken)
+	status, claims := checkAuth(req, "")
+	if status != http.StatusOK {
+		t.Fatalf("携带合法JWT的请求应当通过鉴权, 实际状态码: %d", status)
+	}
+	if claims == nil {
+		t.Fatal("checkAuth 应当返回解析出的 Claims 供按作用域校验")
+	}
+	if !claims.HasScope("send_group_msg") {
+		t.Fatal("登录时声明的作用域应当被保留")
+	}
+	if claims.HasScope("set_group_kick") {
+		t.Fatal("登录时未声明的作用域不应被放行")
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/Mrs4s/go-cqhttp/modules/auth"
+)
+
+// checkAuth 校验请求的鉴权信息, 优先尝试 Authorization: Bearer <jwt>, 解析成功时返回对应的
+// Claims 供 Router 做按作用域的访问控制; 否则回退到旧版静态 token 校验(Authorization: Token
+// <token> 请求头或 ?access_token= 查询参数), 使未启用JWT鉴权的部署方式保持兼容. token 为空
+// 表示未启用鉴权, 直接放行.
+func checkAuth(r *http.Request, token string) (int, *auth.Claims) {
+	if claims, ok := checkBearerAuth(r); ok {
+		return http.StatusOK, claims
+	}
+	if token == "" {
+		return http.StatusOK, nil
+	}
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		if authHeader != "Token "+token {
+			return http.StatusUnauthorized, nil
+		}
+		return http.StatusOK, nil
+	}
+	if t := r.URL.Query().Get("access_token"); t != "" {
+		if t != token {
+			return http.StatusUnauthorized, nil
+		}
+		return http.StatusOK, nil
+	}
+	return http.StatusForbidden, nil
+}
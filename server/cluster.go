@@ -0,0 +1,281 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Mrs4s/go-cqhttp/coolq"
+	"github.com/Mrs4s/go-cqhttp/modules/cluster"
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+// clusterMaster 集群 Master 节点, 聚合已注册 worker 上报的事件, 并将 /api 请求按 self_id 路由回对应 worker
+type clusterMaster struct {
+	conf *config.Cluster
+
+	mu        sync.Mutex
+	eventConn []*wsConn
+	workers   map[int64]*clusterWorkerConn
+}
+
+// clusterWorkerConn 是 Master 侧持有的单个 worker 连接
+type clusterWorkerConn struct {
+	conn   *websocket.Conn
+	node   string
+	selfID int64
+
+	// writeMu 保护 conn.WriteMessage: 同一 self_id 的多个 /api 请求会并发调用 call, gorilla/
+	// websocket 的连接不允许并发写入, 不加锁会导致帧损坏甚至 panic
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan gjson.Result
+}
+
+// runCluster 根据配置以 master 或 worker 模式启动集群子系统
+func runCluster(b *coolq.CQBot, node yaml.Node) {
+	var conf config.Cluster
+	switch err := node.Decode(&conf); {
+	case err != nil:
+		log.Warn("读取集群配置失败 :", err)
+		fallthrough
+	case conf.Disabled:
+		return
+	}
+
+	switch conf.Mode {
+	case "worker":
+		interval := time.Duration(conf.ReconnectInterval) * time.Millisecond
+		w := cluster.NewWorker(b, conf.Master, conf.Node, conf.AccessToken, conf.Filter, interval)
+		go w.Run()
+	case "master":
+		runClusterMaster(&conf)
+	default:
+		log.Warnf("未知的集群运行模式: %v, 应为 master 或 worker", conf.Mode)
+	}
+}
+
+func runClusterMaster(conf *config.Cluster) {
+	m := &clusterMaster{
+		conf:    conf,
+		workers: make(map[int64]*clusterWorkerConn),
+	}
+	addr := conf.Host + ":" + strconv.Itoa(int(conf.Port))
+	mux := http.ServeMux{}
+	mux.HandleFunc("/register", m.register)
+	mux.HandleFunc("/event", m.event)
+	mux.HandleFunc("/api", m.api)
+	log.Infof("集群 Master 已启动: %v", addr)
+	log.Fatal(http.ListenAndServe(addr, &mux))
+}
+
+// register 供 worker 节点连接并注册自身, X-Self-ID/X-Node-Name 由 cluster.Worker 在握手时附带
+func (m *clusterMaster) register(w http.ResponseWriter, r *http.Request) {
+	status, _ := checkAuth(r, m.conf.AccessToken)
+	if status != http.StatusOK {
+		log.Warnf("已拒绝 %v 的集群节点注册请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
+		w.WriteHeader(status)
+		return
+	}
+	selfID, err := strconv.ParseInt(r.Header.Get("X-Self-ID"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	node := r.Header.Get("X-Node-Name")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnf("处理集群节点注册请求时出现错误: %v", err)
+		return
+	}
+
+	wc := &clusterWorkerConn{conn: conn, node: node, selfID: selfID, pending: make(map[string]chan gjson.Result)}
+	m.mu.Lock()
+	old := m.workers[selfID]
+	m.workers[selfID] = wc
+	m.mu.Unlock()
+	if old != nil {
+		// 同一 self_id 重新注册(如 worker 端重连), 旧连接已不会再被使用, 主动关闭以回收其
+		// fd 与 listenWorker goroutine, 否则每次重连都会泄漏一份
+		_ = old.conn.Close()
+	}
+	log.Infof("集群节点 %v (self_id: %d) 已注册", node, selfID)
+	go m.listenWorker(wc)
+}
+
+func (m *clusterMaster) listenWorker(wc *clusterWorkerConn) {
+	defer func() {
+		_ = wc.conn.Close()
+		m.mu.Lock()
+		// register 可能已经用同一 self_id 的新连接替换了 m.workers[wc.selfID](例如 worker
+		// 重连), 此时这里退出的是被取代的旧连接, 不能把新连接的条目也一并删掉
+		if m.workers[wc.selfID] == wc {
+			delete(m.workers, wc.selfID)
+		}
+		m.mu.Unlock()
+		log.Warnf("集群节点 %v (self_id: %d) 已断开", wc.node, wc.selfID)
+	}()
+	for {
+		_, payload, err := wc.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		j := gjson.ParseBytes(payload)
+		switch {
+		case j.Get("_cluster_event").Str == "heartbeat":
+			// 心跳仅用于保活, 无需进一步处理
+		case j.Get("event").Exists():
+			m.broadcastEvent(j.Get("event").Raw)
+		case j.Get("echo").Exists():
+			wc.deliver(j.Get("echo").String(), j)
+		}
+	}
+}
+
+// broadcastEvent 将任一 worker 上报的事件原样推送给所有连接到聚合 /event 端点的消费者
+func (m *clusterMaster) broadcastEvent(raw string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := 0
+	for i := 0; i < len(m.eventConn); i++ {
+		conn := m.eventConn[i]
+		if err := conn.WriteText([]byte(raw)); err != nil {
+			_ = conn.Close()
+			continue
+		}
+		if i != j {
+			m.eventConn[j] = conn
+		}
+		j++
+	}
+	m.eventConn = m.eventConn[:j]
+}
+
+func (m *clusterMaster) event(w http.ResponseWriter, r *http.Request) {
+	status, _ := checkAuth(r, m.conf.AccessToken)
+	if status != http.StatusOK {
+		log.Warnf("已拒绝 %v 的 WebSocket 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
+		w.WriteHeader(status)
+		return
+	}
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnf("处理 WebSocket 请求时出现错误: %v", err)
+		return
+	}
+	log.Infof("接受集群聚合 WebSocket 连接: %v (/event)", r.RemoteAddr)
+	conn := &wsConn{conn: c}
+	m.mu.Lock()
+	m.eventConn = append(m.eventConn, conn)
+	m.mu.Unlock()
+}
+
+// api 按请求体中的 self_id 将调用路由到对应 worker 执行, 并把 worker 回传的结果原样返回
+func (m *clusterMaster) api(w http.ResponseWriter, r *http.Request) {
+	status, _ := checkAuth(r, m.conf.AccessToken)
+	if status != http.StatusOK {
+		log.Warnf("已拒绝 %v 的 API 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
+		w.WriteHeader(status)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	j := gjson.ParseBytes(body)
+	selfID := j.Get("self_id").Int()
+	m.mu.Lock()
+	wc, ok := m.workers[selfID]
+	m.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"status":"failed","retcode":404,"msg":"self_id未连接到本 Master"}`))
+		return
+	}
+
+	ret, err := wc.call(body)
+	if err != nil {
+		log.Warnf("等待集群节点 %v 返回 API 调用结果超时: %v", wc.node, err)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(ret.Raw))
+}
+
+// call 将 payload 转发给 worker 执行, 阻塞等待其带 echo 的回传结果. 转发前会覆盖 payload
+// 中的 echo 字段, 以便将调用方与回传结果一一对应, 调用方原始的 echo 不受影响.
+func (wc *clusterWorkerConn) call(payload []byte) (gjson.Result, error) {
+	var req map[string]interface{}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return gjson.Result{}, err
+	}
+	origEcho, hasEcho := req["echo"]
+	echo := strconv.FormatInt(time.Now().UnixNano(), 36)
+	req["echo"] = echo
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	ch := make(chan gjson.Result, 1)
+	wc.mu.Lock()
+	wc.pending[echo] = ch
+	wc.mu.Unlock()
+
+	wc.writeMu.Lock()
+	err = wc.conn.WriteMessage(websocket.TextMessage, payload)
+	wc.writeMu.Unlock()
+	if err != nil {
+		wc.mu.Lock()
+		delete(wc.pending, echo)
+		wc.mu.Unlock()
+		return gjson.Result{}, err
+	}
+
+	select {
+	case ret := <-ch:
+		var out map[string]interface{}
+		if err := json.Unmarshal([]byte(ret.Raw), &out); err != nil {
+			return gjson.Result{}, err
+		}
+		delete(out, "echo")
+		if hasEcho {
+			out["echo"] = origEcho
+		}
+		b, err := json.Marshal(out)
+		if err != nil {
+			return gjson.Result{}, err
+		}
+		return gjson.ParseBytes(b), nil
+	case <-time.After(time.Second * 30):
+		wc.mu.Lock()
+		delete(wc.pending, echo)
+		wc.mu.Unlock()
+		return gjson.Result{}, fmt.Errorf("等待集群节点 %v 返回结果超时", wc.node)
+	}
+}
+
+func (wc *clusterWorkerConn) deliver(echo string, ret gjson.Result) {
+	wc.mu.Lock()
+	ch, ok := wc.pending[echo]
+	if ok {
+		delete(wc.pending, echo)
+	}
+	wc.mu.Unlock()
+	if ok {
+		ch <- ret
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Mrs4s/go-cqhttp/modules/config"
+)
+
+// dialRegister 模拟 cluster.Worker 向 Master 发起一次 /register 握手
+func dialRegister(t *testing.T, wsURL string, selfID int64) *websocket.Conn {
+	t.Helper()
+	header := http.Header{
+		"X-Self-ID":   []string{strconv.FormatInt(selfID, 10)},
+		"X-Node-Name": []string{"test-node"},
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("注册到测试 Master 失败: %v", err)
+	}
+	return conn
+}
+
+// TestClusterMasterRegisterReplacesAndClosesStaleConn 验证同一 self_id 重新注册(如worker重连)时,
+// Master 会关闭旧连接并且 m.workers 中只保留新连接, 不会残留/泄漏旧的 *clusterWorkerConn
+func TestClusterMasterRegisterReplacesAndClosesStaleConn(t *testing.T) {
+	m := &clusterMaster{
+		conf:    &config.Cluster{},
+		workers: make(map[int64]*clusterWorkerConn),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(m.register))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	const selfID = 12345
+	first := dialRegister(t, wsURL, selfID)
+	defer first.Close()
+
+	// 等待 register 完成首次注册
+	time.Sleep(50 * time.Millisecond)
+	m.mu.Lock()
+	firstWC := m.workers[selfID]
+	m.mu.Unlock()
+	if firstWC == nil {
+		t.Fatal("首次注册后 m.workers 中应存在该 self_id")
+	}
+
+	second := dialRegister(t, wsURL, selfID)
+	defer second.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	m.mu.Lock()
+	secondWC := m.workers[selfID]
+	m.mu.Unlock()
+	if secondWC == nil {
+		t.Fatal("重新注册后 m.workers 中应存在该 self_id")
+	}
+	if secondWC == firstWC {
+		t.Fatal("重新注册后应替换为新的 *clusterWorkerConn, 而不是复用旧连接")
+	}
+
+	// 旧连接应已被 Master 关闭: 对端(first)上的下一次读取应立即返回错误, 而不是一直阻塞
+	_ = first.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := first.ReadMessage(); err == nil {
+		t.Fatal("旧连接被重新注册覆盖后应被 Master 关闭")
+	}
+
+	// listenWorker 为旧连接执行的清理不应影响新连接在 m.workers 中的条目
+	time.Sleep(50 * time.Millisecond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workers[selfID] != secondWC {
+		t.Fatal("旧连接退出时的清理逻辑错误地删除了新连接的条目")
+	}
+}
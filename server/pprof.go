@@ -32,6 +32,7 @@ func runPprof(_ *coolq.CQBot, node yaml.Node) {
 	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/pprof/batch", batchMetricsHandler)
 	server := http.Server{Addr: addr, Handler: mux}
 	go func() {
 		log.Infof("pprof debug 服务器已启动: %v/debug/pprof", addr)
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"golang.org/x/time/rate"
+
+	"github.com/Mrs4s/go-cqhttp/global"
+	"github.com/Mrs4s/go-cqhttp/modules/api"
+)
+
+// rateLimit 返回一个限流中间件, 按 frequency(次/秒) 与 bucket(令牌桶容量) 限制API调用频率,
+// 超出速率的调用被直接拒绝, 不会转发给 caller.Call
+func rateLimit(frequency float64, bucket int) api.Middleware {
+	limiter := rate.NewLimiter(rate.Limit(frequency), bucket)
+	return func(ctx *api.Context, next func(*api.Context)) {
+		if !limiter.Allow() {
+			ctx.Abort(global.MSG{"status": "failed", "retcode": 120, "msg": "rate limit exceeded", "wording": "请求过于频繁"})
+			return
+		}
+		next(ctx)
+	}
+}
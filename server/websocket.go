@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Mrs4s/MiraiGo/utils"
@@ -20,10 +21,36 @@ import (
 	"github.com/Mrs4s/go-cqhttp/coolq"
 	"github.com/Mrs4s/go-cqhttp/global"
 	"github.com/Mrs4s/go-cqhttp/modules/api"
+	"github.com/Mrs4s/go-cqhttp/modules/auth"
 	"github.com/Mrs4s/go-cqhttp/modules/config"
 	"github.com/Mrs4s/go-cqhttp/modules/filter"
 )
 
+// defaultFlushInterval 在 batch_events 开启但未指定 flush_interval_ms 时使用的默认合并窗口
+const defaultFlushInterval = 100 * time.Millisecond
+
+// batchEventsOption 是随 WebsocketServer/WebsocketReverse 节点一起下发、但不属于其固有配置
+// 的批量推送选项, 与 conf 分开解码以免影响原有配置结构
+type batchEventsOption struct {
+	BatchEvents     bool `yaml:"batch_events"`
+	FlushIntervalMs int  `yaml:"flush_interval_ms"`
+	MaxBatchBytes   int  `yaml:"max_batch_bytes"`
+}
+
+// 批量推送相关的运行时计数器, 通过 pprof 调试服务器的 /debug/pprof/batch 端点输出
+var (
+	batchEventsQueued  int64
+	batchEventsFlushed int64
+	batchEventsDropped int64
+)
+
+func (o batchEventsOption) flushInterval() time.Duration {
+	if o.FlushIntervalMs <= 0 {
+		return defaultFlushInterval
+	}
+	return time.Duration(o.FlushIntervalMs) * time.Millisecond
+}
+
 type webSocketServer struct {
 	bot  *coolq.CQBot
 	conf *config.WebsocketServer
@@ -34,6 +61,7 @@ type webSocketServer struct {
 	token     string
 	handshake string
 	filter    string
+	batch     batchEventsOption
 }
 
 // websocketClient WebSocket客户端实例
@@ -46,25 +74,145 @@ type websocketClient struct {
 	token             string
 	filter            string
 	reconnectInterval time.Duration
-	limiter           api.Handler
+	limiter           api.Middleware
+	batch             batchEventsOption
 }
 
 type wsConn struct {
 	mu        sync.Mutex
 	conn      *websocket.Conn
 	apiCaller *api.Caller
+	// router 是 apiCaller 之上的一层action分发器, WS收到的每个调用都通过它而非直接调用
+	// apiCaller.Call, 使得鉴权、限流、日志、指标等横切逻辑可以通过 router.Use 挂载, 并在未来与
+	// HTTP 等其他传输层共享, 而不必在每个transport里各自实现一遍
+	router *api.Router
+	// claims 是 checkAuth 在校验 Authorization: Bearer <jwt> 成功后解出的声明, 用于 Router
+	// 按作用域放行具体的API调用; 未启用JWT鉴权或使用的是legacy AccessToken时为 nil
+	claims *auth.Claims
+
+	// batch 开启后, WriteText 不再逐条写出, 而是在 batchBuf 中合并成一个 JSON 数组,
+	// 由 runBatchFlusher 按 flushInterval 周期写出, 或在 enqueue 中达到 maxBatchBytes 时立即写出, 以减少高事件量下的系统调用次数
+	batch         bool
+	flushInterval time.Duration
+	maxBatchBytes int
+	batchBuf      *bytes.Buffer
+	batchCount    int
+	// flushErr 记录上一次合并写入失败的错误. 批量模式下 WriteText 在事件入队时通常立即返回
+	// nil, 真正的写入由 runBatchFlusher 异步完成; 一旦后台写入失败就必须记在这里, 让下一次
+	// enqueue 把它如实返回给调用方, 否则依赖 WriteText 报错来判定连接已死、进而重连/摘除连接
+	// 的现有逻辑(onBotPushEvent、webSocketServer.onBotPushEvent)会永远感知不到连接已经断开
+	flushErr  error
+	closeOnce sync.Once
+	stopFlush chan struct{}
+}
+
+// newWsConn 构建一个 wsConn, 当 opt.BatchEvents 为 true 时同时启动后台合并写入协程
+func newWsConn(conn *websocket.Conn, caller *api.Caller, opt batchEventsOption) *wsConn {
+	c := &wsConn{
+		conn:          conn,
+		apiCaller:     caller,
+		router:        api.NewRouter(caller),
+		batch:         opt.BatchEvents,
+		flushInterval: opt.flushInterval(),
+		maxBatchBytes: opt.MaxBatchBytes,
+	}
+	if c.batch {
+		c.stopFlush = make(chan struct{})
+		go c.runBatchFlusher()
+	}
+	return c
 }
 
 func (c *wsConn) WriteText(b []byte) error {
+	if c.batch {
+		return c.enqueue(b)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.conn.WriteMessage(websocket.TextMessage, b)
 }
 
+// enqueue 将事件暂存进 batchBuf, 在达到 maxBatchBytes 时立即触发一次合并写入
+func (c *wsConn) enqueue(b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flushErr != nil {
+		return c.flushErr
+	}
+	if c.batchBuf == nil {
+		c.batchBuf = global.NewBuffer()
+		c.batchBuf.WriteByte('[')
+	} else {
+		c.batchBuf.WriteByte(',')
+	}
+	c.batchBuf.Write(b)
+	c.batchCount++
+	atomic.AddInt64(&batchEventsQueued, 1)
+	if c.maxBatchBytes > 0 && c.batchBuf.Len() >= c.maxBatchBytes {
+		return c.flushLocked()
+	}
+	return nil
+}
+
+// flushLocked 假定调用方已持有 c.mu, 将暂存的事件合并为一条 JSON 数组消息写出
+func (c *wsConn) flushLocked() error {
+	if c.batchBuf == nil || c.batchCount == 0 {
+		return nil
+	}
+	c.batchBuf.WriteByte(']')
+	err := c.conn.WriteMessage(websocket.TextMessage, c.batchBuf.Bytes())
+	global.PutBuffer(c.batchBuf)
+	c.batchBuf = nil
+	if err == nil {
+		atomic.AddInt64(&batchEventsFlushed, int64(c.batchCount))
+	} else {
+		atomic.AddInt64(&batchEventsDropped, int64(c.batchCount))
+		c.flushErr = err
+	}
+	c.batchCount = 0
+	return err
+}
+
+func (c *wsConn) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flushLocked()
+}
+
+// runBatchFlusher 按 flushInterval 周期性地将暂存事件合并写出, 避免长期没有新事件时
+// 已入队的事件被无限期滞留. 合并写入一旦失败就主动关闭底层连接并退出: flushErr 会让后续的
+// enqueue 把这个错误如实返回给调用方, 使 onBotPushEvent 等既有的"写入失败则重连/摘除连接"
+// 逻辑照常触发, 而不是被这里悄悄吞掉
+func (c *wsConn) runBatchFlusher() {
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopFlush:
+			return
+		case <-ticker.C:
+			if err := c.flush(); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
 func (c *wsConn) Close() error {
+	if c.batch {
+		c.closeOnce.Do(func() { close(c.stopFlush) })
+	}
 	return c.conn.Close()
 }
 
+// batchMetricsHandler 以纯文本形式输出批量推送相关的计数器, 挂载于 pprof 调试服务器之上
+func batchMetricsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "batch_events_queued %d\nbatch_events_flushed %d\nbatch_events_dropped %d\n",
+		atomic.LoadInt64(&batchEventsQueued), atomic.LoadInt64(&batchEventsFlushed), atomic.LoadInt64(&batchEventsDropped))
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -82,11 +230,15 @@ func runWSServer(b *coolq.CQBot, node yaml.Node) {
 		return
 	}
 
+	var batch batchEventsOption
+	_ = node.Decode(&batch)
+
 	s := &webSocketServer{
 		bot:    b,
 		conf:   &conf,
 		token:  conf.AccessToken,
 		filter: conf.Filter,
+		batch:  batch,
 	}
 	filter.Add(s.filter)
 	addr := fmt.Sprintf("%s:%d", conf.Host, conf.Port)
@@ -96,6 +248,7 @@ func runWSServer(b *coolq.CQBot, node yaml.Node) {
 	mux := http.ServeMux{}
 	mux.HandleFunc("/event", s.event)
 	mux.HandleFunc("/api", s.api)
+	mux.HandleFunc("/auth/login", authLoginHandler)
 	mux.HandleFunc("/", s.any)
 	log.Infof("CQ WebSocket 服务器已启动: %v", addr)
 	log.Fatal(http.ListenAndServe(addr, &mux))
@@ -112,10 +265,14 @@ func runWSClient(b *coolq.CQBot, node yaml.Node) {
 		return
 	}
 
+	var batch batchEventsOption
+	_ = node.Decode(&batch)
+
 	c := &websocketClient{
 		bot:    b,
 		token:  conf.AccessToken,
 		filter: conf.Filter,
+		batch:  batch,
 	}
 	filter.Add(c.filter)
 	if conf.ReconnectInterval != 0 {
@@ -169,9 +326,9 @@ func (c *websocketClient) connect(typ, url string, conptr **wsConn) {
 	}
 
 	log.Infof("已连接到反向WebSocket %s服务器 %v", typ, url)
-	wrappedConn := &wsConn{conn: conn, apiCaller: api.NewCaller(c.bot)}
+	wrappedConn := newWsConn(conn, api.NewCaller(c.bot), c.batch)
 	if c.limiter != nil {
-		wrappedConn.apiCaller.Use(c.limiter)
+		wrappedConn.router.Use(c.limiter)
 	}
 
 	if conptr != nil {
@@ -238,7 +395,7 @@ func (c *websocketClient) onBotPushEvent(typ, url string, conn **wsConn) func(e
 }
 
 func (s *webSocketServer) event(w http.ResponseWriter, r *http.Request) {
-	status := checkAuth(r, s.token)
+	status, claims := checkAuth(r, s.token)
 	if status != http.StatusOK {
 		log.Warnf("已拒绝 %v 的 WebSocket 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
 		w.WriteHeader(status)
@@ -259,14 +416,15 @@ func (s *webSocketServer) event(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Infof("接受 WebSocket 连接: %v (/event)", r.RemoteAddr)
-	conn := &wsConn{conn: c, apiCaller: api.NewCaller(s.bot)}
+	conn := newWsConn(c, api.NewCaller(s.bot), s.batch)
+	conn.claims = claims
 	s.mu.Lock()
 	s.eventConn = append(s.eventConn, conn)
 	s.mu.Unlock()
 }
 
 func (s *webSocketServer) api(w http.ResponseWriter, r *http.Request) {
-	status := checkAuth(r, s.token)
+	status, claims := checkAuth(r, s.token)
 	if status != http.StatusOK {
 		log.Warnf("已拒绝 %v 的 WebSocket 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
 		w.WriteHeader(status)
@@ -280,15 +438,16 @@ func (s *webSocketServer) api(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Infof("接受 WebSocket 连接: %v (/api)", r.RemoteAddr)
-	conn := &wsConn{conn: c, apiCaller: api.NewCaller(s.bot)}
+	conn := newWsConn(c, api.NewCaller(s.bot), s.batch)
+	conn.claims = claims
 	if s.conf.RateLimit.Enabled {
-		conn.apiCaller.Use(rateLimit(s.conf.RateLimit.Frequency, s.conf.RateLimit.Bucket))
+		conn.router.Use(rateLimit(s.conf.RateLimit.Frequency, s.conf.RateLimit.Bucket))
 	}
 	s.listenAPI(conn)
 }
 
 func (s *webSocketServer) any(w http.ResponseWriter, r *http.Request) {
-	status := checkAuth(r, s.token)
+	status, claims := checkAuth(r, s.token)
 	if status != http.StatusOK {
 		log.Warnf("已拒绝 %v 的 WebSocket 请求: Token鉴权失败(code:%d)", r.RemoteAddr, status)
 		w.WriteHeader(status)
@@ -309,9 +468,10 @@ func (s *webSocketServer) any(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Infof("接受 WebSocket 连接: %v (/)", r.RemoteAddr)
-	conn := &wsConn{conn: c, apiCaller: api.NewCaller(s.bot)}
+	conn := newWsConn(c, api.NewCaller(s.bot), s.batch)
+	conn.claims = claims
 	if s.conf.RateLimit.Enabled {
-		conn.apiCaller.Use(rateLimit(s.conf.RateLimit.Frequency, s.conf.RateLimit.Bucket))
+		conn.router.Use(rateLimit(s.conf.RateLimit.Frequency, s.conf.RateLimit.Bucket))
 	}
 	s.mu.Lock()
 	s.eventConn = append(s.eventConn, conn)
@@ -351,12 +511,15 @@ func (c *wsConn) handleRequest(_ *coolq.CQBot, payload []byte) {
 		}
 	}()
 	j := gjson.Parse(utils.B2S(payload))
-	t := strings.TrimSuffix(j.Get("action").Str, "_async")
-	log.Debugf("WS接收到API调用: %v 参数: %v", t, j.Get("params").Raw)
-	ret := c.apiCaller.Call(t, j.Get("params"))
-	if j.Get("echo").Exists() {
-		ret["echo"] = j.Get("echo").Value()
-	}
+	ctx := &api.Context{
+		Action:    strings.TrimSuffix(j.Get("action").Str, "_async"),
+		Params:    j.Get("params"),
+		Echo:      j.Get("echo"),
+		Transport: "ws",
+		Claims:    c.claims,
+	}
+	log.Debugf("WS接收到API调用: %v 参数: %v", ctx.Action, ctx.Params.Raw)
+	ret := c.router.Dispatch(ctx)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
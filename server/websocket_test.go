@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestWsConnBatchFlushFailurePropagates 验证 batch_events 开启时, 后台合并写入一旦失败,
+// 这个错误必须被下一次 WriteText 如实返回, 否则依赖 WriteText 报错来判定连接已死的调用方
+// (onBotPushEvent 等)永远不会发起重连/摘除这条已经断开的连接
+func TestWsConnBatchFlushFailurePropagates(t *testing.T) {
+	var serverConn *wsConn
+	ready := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("升级WS连接失败: %v", err)
+			return
+		}
+		serverConn = newWsConn(c, nil, batchEventsOption{BatchEvents: true, FlushIntervalMs: 20})
+		close(ready)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接测试服务器失败: %v", err)
+	}
+	defer client.Close()
+	<-ready
+
+	if err := serverConn.WriteText([]byte(`{"a":1}`)); err != nil {
+		t.Fatalf("批量模式下事件入队不应立即返回错误: %v", err)
+	}
+
+	// 关闭客户端连接. 对端关闭后的第一次 TCP 写入通常仍会成功, 之后的写入才会失败,
+	// 因此持续入队事件, 直到某次周期性合并写入失败
+	_ = client.Close()
+	var werr error
+	for i := 0; i < 20 && werr == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		werr = serverConn.WriteText([]byte(`{"a":2}`))
+	}
+	if werr == nil {
+		t.Fatal("底层连接已断开、后台flush失败后, WriteText 应将错误如实返回给调用方")
+	}
+}